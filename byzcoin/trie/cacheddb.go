@@ -0,0 +1,290 @@
+package trie
+
+import (
+	"sort"
+	"sync"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+// defaultMaxEntries is used when CacheOpts.MaxEntries is left at zero.
+const defaultMaxEntries = 10000
+
+// CacheOpts configures a CachedDB.
+type CacheOpts struct {
+	// MaxEntries bounds how many (k, v) pairs the read LRU keeps in memory.
+	// Zero picks defaultMaxEntries.
+	MaxEntries int
+}
+
+// Stats reports how a CachedDB's read cache and write batching have
+// performed so far.
+type Stats struct {
+	Hits, Misses, Batches uint64
+}
+
+// CachedDB wraps a diskDB with an LRU of recently read (k, v) pairs and
+// coalesces every Put/Delete issued inside one Update closure into a
+// single flush against bbolt, instead of hitting bolt separately for each
+// one. It implements the same DB interface as diskDB, so it is a drop-in
+// replacement on hot paths such as ByzCoin tipset replay or Calypso write
+// reindexing.
+type CachedDB struct {
+	disk DB
+
+	mu                    sync.Mutex
+	cache                 *lru
+	hits, misses, batches uint64
+	// gen counts committed Update calls. A cachingBucket read that misses
+	// the cache stamps the gen it saw before going to disk, and only
+	// populates the cache if gen is unchanged once the disk read returns -
+	// otherwise a concurrent Update could have changed that very key on
+	// disk in between, and caching our now-stale read would poison the
+	// cache with a value an Update has already superseded.
+	gen uint64
+}
+
+// NewCachedDiskDB creates a boltdb-backed DB wrapped in the read cache and
+// write batching described on CachedDB.
+func NewCachedDiskDB(db *bolt.DB, bucket []byte, opts CacheOpts) DB {
+	max := opts.MaxEntries
+	if max <= 0 {
+		max = defaultMaxEntries
+	}
+	return &CachedDB{
+		disk:  NewDiskDB(db, bucket),
+		cache: newLRU(max),
+	}
+}
+
+// Stats returns the current hit/miss/batch counters.
+func (c *CachedDB) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Hits: c.hits, Misses: c.misses, Batches: c.batches}
+}
+
+// Update runs f against a single bbolt transaction. Every Put/Delete f
+// issues is buffered in an in-memory overlay - so repeated writes to the
+// same key are coalesced into their final value - and only flushed to
+// bbolt once f returns successfully. The overlay also gives f
+// read-your-writes semantics: a Get sees a key's pending value before it
+// has been flushed.
+func (c *CachedDB) Update(f func(bucket) error) error {
+	ov := newOverlayBucket(c)
+	err := c.disk.Update(func(b bucket) error {
+		ov.under = b
+		if err := f(ov); err != nil {
+			return err
+		}
+		return ov.flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.batches++
+	c.gen++
+	for _, key := range ov.order {
+		if v := ov.writes[key]; v == nil {
+			c.cache.remove([]byte(key))
+		} else {
+			c.cache.add([]byte(key), v)
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// View runs f against a single read-only bbolt transaction. Reads that hit
+// the LRU never touch bbolt.
+func (c *CachedDB) View(f func(bucket) error) error {
+	return c.disk.View(func(b bucket) error {
+		return f(&cachingBucket{c: c, under: b})
+	})
+}
+
+// UpdateDryRun runs f exactly like Update, but the whole transaction is
+// rolled back afterwards: the overlay gives f read-your-writes semantics
+// during the dry run, but nothing is flushed to bbolt or to the cache.
+func (c *CachedDB) UpdateDryRun(f func(bucket) error) error {
+	ov := newOverlayBucket(c)
+	return c.disk.UpdateDryRun(func(b bucket) error {
+		ov.under = b
+		return f(ov)
+	})
+}
+
+// Close closes the underlying diskDB.
+func (c *CachedDB) Close() error {
+	return c.disk.Close()
+}
+
+// cachingBucket wraps a bucket with read-through LRU caching: Get checks
+// the cache before falling back to the wrapped bucket, and populates the
+// cache on a miss. Every slice Get returns is the caller's own copy, so
+// mutating it leaves the cached value untouched.
+type cachingBucket struct {
+	c     *CachedDB
+	under bucket
+}
+
+func (b *cachingBucket) Get(k []byte) []byte {
+	b.c.mu.Lock()
+	if v, ok := b.c.cache.get(k); ok {
+		b.c.hits++
+		cp := make([]byte, len(v))
+		copy(cp, v)
+		b.c.mu.Unlock()
+		return cp
+	}
+	b.c.misses++
+	genAtStart := b.c.gen
+	b.c.mu.Unlock()
+
+	v := b.under.Get(k)
+	if v == nil {
+		return nil
+	}
+	// v is owned by bbolt's mmap and only valid for the life of this
+	// transaction: copy it before it can reach the cache, and hand the
+	// caller a second copy so it cannot alias the cache entry either.
+	cp := make([]byte, len(v))
+	copy(cp, v)
+
+	b.c.mu.Lock()
+	if b.c.gen == genAtStart {
+		b.c.cache.add(k, cp)
+	}
+	b.c.mu.Unlock()
+	out := make([]byte, len(cp))
+	copy(out, cp)
+	return out
+}
+
+func (b *cachingBucket) Put(k, v []byte) error {
+	return b.under.Put(k, v)
+}
+
+func (b *cachingBucket) Delete(k []byte) error {
+	return b.under.Delete(k)
+}
+
+func (b *cachingBucket) ForEach(f func(k, v []byte) error) error {
+	return b.under.ForEach(f)
+}
+
+// overlayBucket buffers the Put/Delete calls issued during a single Update
+// or UpdateDryRun in memory, so that repeated writes to the same key
+// collapse into one flush, and so that reads within the same transaction
+// see pending writes before they are committed. A nil value in writes
+// means the key is pending deletion.
+type overlayBucket struct {
+	c     *CachedDB
+	under bucket
+
+	writes map[string][]byte
+	order  []string
+}
+
+func newOverlayBucket(c *CachedDB) *overlayBucket {
+	return &overlayBucket{c: c, writes: make(map[string][]byte)}
+}
+
+func (o *overlayBucket) Get(k []byte) []byte {
+	key := string(k)
+	if v, ok := o.writes[key]; ok {
+		return v
+	}
+
+	o.c.mu.Lock()
+	if v, ok := o.c.cache.get(k); ok {
+		o.c.hits++
+		o.c.mu.Unlock()
+		return v
+	}
+	o.c.misses++
+	o.c.mu.Unlock()
+
+	v := o.under.Get(k)
+	if v == nil {
+		return nil
+	}
+	cp := make([]byte, len(v))
+	copy(cp, v)
+	return cp
+}
+
+func (o *overlayBucket) Put(k, v []byte) error {
+	cp := make([]byte, len(v))
+	copy(cp, v)
+	o.stage(string(k), cp)
+	return nil
+}
+
+func (o *overlayBucket) Delete(k []byte) error {
+	o.stage(string(k), nil)
+	return nil
+}
+
+func (o *overlayBucket) stage(key string, v []byte) {
+	if _, pending := o.writes[key]; !pending {
+		o.order = append(o.order, key)
+	}
+	o.writes[key] = v
+}
+
+// ForEach merges the staged writes over under's contents and replays them
+// to f in ascending key order, matching bbolt's own ForEach contract: a
+// caller that relies on sorted iteration (e.g. a trie walk) must see the
+// same order whether or not it happens to be inside an Update closure.
+func (o *overlayBucket) ForEach(f func(k, v []byte) error) error {
+	merged := make(map[string][]byte, len(o.order))
+	err := o.under.ForEach(func(k, v []byte) error {
+		cp := make([]byte, len(v))
+		copy(cp, v)
+		merged[string(k)] = cp
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range o.order {
+		if v := o.writes[key]; v == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = v
+		}
+	}
+
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := f([]byte(k), merged[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes every buffered change to under, in the order it was first
+// staged.
+func (o *overlayBucket) flush() error {
+	for _, key := range o.order {
+		v := o.writes[key]
+		if v == nil {
+			if err := o.under.Delete([]byte(key)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.under.Put([]byte(key), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
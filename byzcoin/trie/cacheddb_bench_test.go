@@ -0,0 +1,87 @@
+package trie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+var benchBucket = []byte("bench")
+
+func openBenchDB(tb testing.TB) (*bolt.DB, func()) {
+	f, err := ioutil.TempFile("", "cacheddb-bench-")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := f.Name()
+	f.Close()
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(benchBucket)
+		return err
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.Remove(path)
+	}
+}
+
+// runMixedWorkload drives n mixed Put/Get operations against db: every
+// fifth key is a fresh write, the rest are reads of an already-written key,
+// which is the read-heavy, write-coalescing pattern CachedDB targets.
+func runMixedWorkload(b *testing.B, db DB, n int) {
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key-%d", (i-i%5)%1000))
+		if i%5 == 0 {
+			if err := db.Update(func(bk bucket) error {
+				return bk.Put(key, []byte(fmt.Sprintf("value-%d", i)))
+			}); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		if err := db.View(func(bk bucket) error {
+			bk.Get(key)
+			return nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDiskDBMixedWorkload exercises 100k mixed reads/writes directly
+// against bbolt, with no caching or batching.
+func BenchmarkDiskDBMixedWorkload(b *testing.B) {
+	db, cleanup := openBenchDB(b)
+	defer cleanup()
+	disk := NewDiskDB(db, benchBucket)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runMixedWorkload(b, disk, 100000)
+	}
+}
+
+// BenchmarkCachedDBMixedWorkload exercises the same 100k mixed
+// reads/writes through CachedDB, so the speedup from its read cache and
+// write batching shows up directly against BenchmarkDiskDBMixedWorkload.
+func BenchmarkCachedDBMixedWorkload(b *testing.B) {
+	db, cleanup := openBenchDB(b)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, benchBucket, CacheOpts{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runMixedWorkload(b, cached, 100000)
+	}
+}
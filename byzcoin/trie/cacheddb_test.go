@@ -0,0 +1,241 @@
+package trie
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+var testBucket = []byte("test")
+
+func openTestDB(t *testing.T) (*bolt.DB, func()) {
+	f, err := ioutil.TempFile("", "cacheddb-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := f.Name()
+	f.Close()
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(testBucket)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.Remove(path)
+	}
+}
+
+// TestCachedDBGetDoesNotAliasCacheEntry writes a key, reads it back once,
+// then overwrites every byte of the slice Get returned. A second Get must
+// still return the value the key was written with, not the caller's
+// mutated bytes.
+func TestCachedDBGetDoesNotAliasCacheEntry(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, testBucket, CacheOpts{})
+
+	key, first := []byte("k"), []byte("first-value")
+	if err := cached.Update(func(b bucket) error { return b.Put(key, first) }); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	if err := cached.View(func(b bucket) error {
+		got = b.Get(key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "first-value" {
+		t.Fatalf("got %q, want %q", got, "first-value")
+	}
+
+	// Mutate the caller's own copy: if cachingBucket.Get had returned the
+	// cached slice itself instead of a defensive copy, this would corrupt
+	// the cache entry too.
+	for i := range got {
+		got[i] = 'X'
+	}
+
+	var cachedAgain []byte
+	if err := cached.View(func(b bucket) error {
+		cachedAgain = b.Get(key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if string(cachedAgain) != "first-value" {
+		t.Fatalf("cache was corrupted by a caller mutating a returned slice: got %q", cachedAgain)
+	}
+}
+
+// TestCachedDBReadYourWrites checks that a Get inside an Update sees a
+// value staged earlier in the same closure, before it has been flushed to
+// bbolt.
+func TestCachedDBReadYourWrites(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, testBucket, CacheOpts{})
+
+	key := []byte("k")
+	err := cached.Update(func(b bucket) error {
+		if err := b.Put(key, []byte("staged")); err != nil {
+			return err
+		}
+		if got := b.Get(key); string(got) != "staged" {
+			t.Fatalf("expected to read back a pending write, got %q", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCachedDBEviction checks that the LRU evicts its least-recently-used
+// entry once MaxEntries is exceeded.
+func TestCachedDBEviction(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, testBucket, CacheOpts{MaxEntries: 2}).(*CachedDB)
+
+	put := func(k, v string) {
+		if err := cached.Update(func(b bucket) error { return b.Put([]byte(k), []byte(v)) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(k string) []byte {
+		var v []byte
+		if err := cached.View(func(b bucket) error { v = b.Get([]byte(k)); return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	put("a", "1")
+	put("b", "2")
+	get("a") // touch "a" so it is more recently used than "b"
+	put("c", "3")
+
+	if cached.cache.ll.Len() > 2 {
+		t.Fatalf("expected the cache to hold at most 2 entries, holds %d", cached.cache.ll.Len())
+	}
+	if _, ok := cached.cache.get([]byte("b")); ok {
+		t.Fatal("expected \"b\" to have been evicted as the least-recently-used entry")
+	}
+	if v := get("a"); string(v) != "1" {
+		t.Fatalf("expected \"a\" to still be served, got %q", v)
+	}
+	if v := get("c"); string(v) != "3" {
+		t.Fatalf("expected \"c\" to still be served, got %q", v)
+	}
+}
+
+// TestCachedDBStats checks that hit/miss/batch counters track actual
+// lookups and Update calls.
+func TestCachedDBStats(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, testBucket, CacheOpts{}).(*CachedDB)
+
+	key := []byte("k")
+	if err := cached.Update(func(b bucket) error { return b.Put(key, []byte("v")) }); err != nil {
+		t.Fatal(err)
+	}
+
+	miss := func() {
+		if err := cached.View(func(b bucket) error { b.Get([]byte("not-cached")); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hit := func() {
+		if err := cached.View(func(b bucket) error { b.Get(key); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	miss()
+	hit()
+	hit()
+
+	st := cached.Stats()
+	if st.Batches != 1 {
+		t.Fatalf("expected 1 batch from the single Update, got %d", st.Batches)
+	}
+	if st.Misses != 1 {
+		t.Fatalf("expected 1 miss, got %d", st.Misses)
+	}
+	if st.Hits != 2 {
+		t.Fatalf("expected 2 hits, got %d", st.Hits)
+	}
+}
+
+// TestOverlayBucketForEachMergesInSortedOrder checks that ForEach replays
+// both the bucket's existing contents and the keys staged in the current
+// Update, merged into a single ascending-key-order stream - matching
+// bbolt's own ForEach contract - rather than staged keys first.
+func TestOverlayBucketForEachMergesInSortedOrder(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	cached := NewCachedDiskDB(db, testBucket, CacheOpts{})
+
+	if err := cached.Update(func(b bucket) error {
+		if err := b.Put([]byte("b"), []byte("2")); err != nil {
+			return err
+		}
+		return b.Put([]byte("d"), []byte("4"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cached.Update(func(b bucket) error {
+		// Stage a new key ("a"), an update to an existing one ("d"),
+		// and a deletion ("b"), all out of sorted order.
+		if err := b.Put([]byte("d"), []byte("4-updated")); err != nil {
+			return err
+		}
+		if err := b.Delete([]byte("b")); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("a"), []byte("1")); err != nil {
+			return err
+		}
+
+		var keys []string
+		var values []string
+		err := b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			values = append(values, string(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		wantKeys := []string{"a", "d"}
+		if len(keys) != len(wantKeys) {
+			t.Fatalf("expected keys %v, got %v", wantKeys, keys)
+		}
+		for i, k := range wantKeys {
+			if keys[i] != k {
+				t.Fatalf("expected keys in sorted order %v, got %v", wantKeys, keys)
+			}
+		}
+		if values[0] != "1" || values[1] != "4-updated" {
+			t.Fatalf("expected merged values [1 4-updated], got %v", values)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package trie
+
+import "container/list"
+
+// lruEntry is the payload stored in lru's linked list.
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// lru is a minimal least-recently-used cache of byte-slice values, keyed by
+// the string form of the original byte-slice key. It backs CachedDB's read
+// cache and is not safe for concurrent use; callers must serialize access
+// themselves.
+type lru struct {
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// newLRU creates an lru that evicts its least-recently-used entry once it
+// holds more than max items.
+func newLRU(max int) *lru {
+	return &lru{
+		max:   max,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached value for k, if any, and marks it most recently
+// used.
+func (c *lru) get(k []byte) ([]byte, bool) {
+	el, ok := c.items[string(k)]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// add inserts or updates the cached value for k, evicting the
+// least-recently-used entry if the cache is now over capacity.
+func (c *lru) add(k, v []byte) {
+	key := string(k)
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*lruEntry).value = v
+		return
+	}
+	el := c.ll.PushFront(&lruEntry{key: key, value: v})
+	c.items[key] = el
+	if c.max > 0 && c.ll.Len() > c.max {
+		c.evictOldest()
+	}
+}
+
+// remove evicts k from the cache, if present.
+func (c *lru) remove(k []byte) {
+	key := string(k)
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+func (c *lru) evictOldest() {
+	el := c.ll.Back()
+	if el == nil {
+		return
+	}
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
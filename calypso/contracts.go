@@ -2,11 +2,11 @@ package calypso
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/dedis/cothority"
 	"github.com/dedis/cothority/byzcoin"
 	"github.com/dedis/cothority/darc"
-	"github.com/dedis/onet"
 	"github.com/dedis/onet/log"
 	"github.com/dedis/onet/network"
 	"github.com/dedis/protobuf"
@@ -25,7 +25,7 @@ func contractWriteFromBytes(in []byte) (byzcoin.Contract, error) {
 
 	err := protobuf.DecodeWithConstructors(in, &c.Write, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
-		return nil, errors.New("couldn't unmarshal write: " + err.Error())
+		return nil, newCalypsoError(ErrBadArgs, "couldn't unmarshal write: "+err.Error(), nil)
 	}
 	return c, nil
 }
@@ -43,16 +43,22 @@ func (c *contractWr) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructi
 	case ContractWriteID:
 		w := inst.Spawn.Args.Search("write")
 		if w == nil || len(w) == 0 {
-			err = errors.New("need a write request in 'write' argument")
+			err = newCalypsoError(ErrBadArgs, "need a write request in 'write' argument", nil)
 			return
 		}
 		err = protobuf.DecodeWithConstructors(w, &c.Write, network.DefaultConstructors(cothority.Suite))
 		if err != nil {
-			err = errors.New("couldn't unmarshal write: " + err.Error())
+			err = newCalypsoError(ErrBadArgs, "couldn't unmarshal write: "+err.Error(), nil)
 			return
 		}
 		if err = c.Write.CheckProof(cothority.Suite, darcID); err != nil {
-			err = errors.New("proof of write failed: " + err.Error())
+			err = newCalypsoError(ErrWriteProofFailed, err.Error(), darcID)
+			return
+		}
+		c.Write.Version = CalypsoNetworkVersion
+		w, err = protobuf.Encode(&c.Write)
+		if err != nil {
+			err = newCalypsoError(ErrBadArgs, "couldn't re-marshal write: "+err.Error(), nil)
 			return
 		}
 		instID := inst.DeriveID("")
@@ -62,22 +68,40 @@ func (c *contractWr) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructi
 		var rd Read
 		r := inst.Spawn.Args.Search("read")
 		if r == nil || len(r) == 0 {
-			return nil, nil, errors.New("need a read argument")
+			return nil, nil, newCalypsoError(ErrBadArgs, "need a read argument", nil)
 		}
 		err = protobuf.DecodeWithConstructors(r, &rd, network.DefaultConstructors(cothority.Suite))
 		if err != nil {
-			return nil, nil, errors.New("passed read argument is invalid: " + err.Error())
+			return nil, nil, newCalypsoError(ErrBadArgs, "passed read argument is invalid: "+err.Error(), nil)
 		}
-		_, _, wc, _, err := rst.GetValues(rd.Write.Slice())
+		wbuf, _, wc, wDarcID, err := rst.GetValues(rd.Write.Slice())
 		if err != nil {
-			return nil, nil, errors.New("referenced write-id is not correct: " + err.Error())
+			return nil, nil, newCalypsoError(ErrBadArgs, "referenced write-id is not correct: "+err.Error(), rd.Write)
 		}
 		if wc != ContractWriteID {
-			return nil, nil, errors.New("referenced write-id is not a write instance, got " + wc)
+			return nil, nil, newCalypsoError(ErrReadRefNotWrite, "got "+wc, rd.Write)
+		}
+		var w Write
+		err = protobuf.DecodeWithConstructors(wbuf, &w, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, newCalypsoError(ErrBadArgs, "stored write is invalid: "+err.Error(), rd.Write)
+		}
+		var extra byzcoin.StateChange
+		cout, extra, err = chargePrice(rst, cout, w.Price, w.PriceCurrency, w.Payee, wDarcID)
+		if err != nil {
+			return nil, nil, err
+		}
+		rd.Version = CalypsoNetworkVersion
+		r, err = protobuf.Encode(&rd)
+		if err != nil {
+			return nil, nil, newCalypsoError(ErrBadArgs, "couldn't re-marshal read: "+err.Error(), nil)
 		}
 		sc = byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), ContractReadID, r, darcID)}
+		if w.Price > 0 {
+			sc = append(sc, extra)
+		}
 	default:
-		err = errors.New("can only spawn writes and reads")
+		err = newCalypsoError(ErrBadArgs, "can only spawn writes and reads", nil)
 	}
 	return
 }
@@ -107,7 +131,7 @@ func contractLTSFromBytes(in []byte) (byzcoin.Contract, error) {
 
 	err := protobuf.DecodeWithConstructors(in, &c.LtsInstanceInfo, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
-		return nil, errors.New("couldn't unmarshal LtsInfo: " + err.Error())
+		return nil, newCalypsoError(ErrLTSInfoInvalid, err.Error(), nil)
 	}
 	return c, nil
 }
@@ -120,16 +144,21 @@ func (c *contractLTS) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruct
 	}
 
 	if inst.Spawn.ContractID != ContractLongTermSecretID {
-		return nil, nil, errors.New("can only spawn long-term-secret instances")
+		return nil, nil, newCalypsoError(ErrBadArgs, "can only spawn long-term-secret instances", nil)
 	}
 	infoBuf := inst.Spawn.Args.Search("lts_instance_info")
 	if infoBuf == nil || len(infoBuf) == 0 {
-		return nil, nil, errors.New("need a lts_instance_info argument")
+		return nil, nil, newCalypsoError(ErrBadArgs, "need a lts_instance_info argument", nil)
 	}
 	var info LtsInstanceInfo
 	err = protobuf.DecodeWithConstructors(infoBuf, &info, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
-		return nil, nil, errors.New("passed lts_instance_info argument is invalid: " + err.Error())
+		return nil, nil, newCalypsoError(ErrLTSInfoInvalid, "passed lts_instance_info argument is invalid: "+err.Error(), nil)
+	}
+	info.Version = CalypsoNetworkVersion
+	infoBuf, err = protobuf.Encode(&info)
+	if err != nil {
+		return nil, nil, newCalypsoError(ErrBadArgs, "couldn't re-marshal lts_instance_info: "+err.Error(), nil)
 	}
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
@@ -142,41 +171,64 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 	}
 
 	if inst.Invoke.Command != "reshare" {
-		return nil, nil, errors.New("can only reshare long-term secrets")
+		return nil, nil, newCalypsoError(ErrBadArgs, "can only reshare long-term secrets", nil)
 	}
 	infoBuf := inst.Invoke.Args.Search("lts_instance_info")
 	if infoBuf == nil || len(infoBuf) == 0 {
-		return nil, nil, errors.New("need a lts_instance_info argument")
+		return nil, nil, newCalypsoError(ErrBadArgs, "need a lts_instance_info argument", nil)
 	}
 
 	var curInfo, newInfo LtsInstanceInfo
 	err = protobuf.DecodeWithConstructors(infoBuf, &newInfo, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
-		return nil, nil, errors.New("passed lts_instance_info argument is invalid: " + err.Error())
+		return nil, nil, newCalypsoError(ErrLTSInfoInvalid, "passed lts_instance_info argument is invalid: "+err.Error(), nil)
 	}
 	err = protobuf.DecodeWithConstructors(curBuf, &curInfo, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
-		return nil, nil, errors.New("current info is invalid: " + err.Error())
+		return nil, nil, newCalypsoError(ErrLTSInfoInvalid, "current info is invalid: "+err.Error(), nil)
 	}
 
-	// Verify the intersection between new roster and the old one. There must be
-	// at least a threshold of nodes in the intersection.
-	n := len(curInfo.Roster.List)
-	overlap := intersectRosters(&curInfo.Roster, &newInfo.Roster)
-	thr := n - (n-1)/3
-	if overlap < thr {
-		return nil, nil, errors.New("new roster does not overlap enough with current roster")
+	// The on-chain Version is always pinned to CalypsoNetworkVersion here,
+	// exactly as Spawn pins it on every instance it creates: a client must
+	// never get to choose what version its reshare gets persisted at, or
+	// an arbitrarily large client-supplied Version would permanently brick
+	// the instance (no migrator chain could ever reach it, and the
+	// no-downgrade check below would then reject every future reshare).
+	newInfo.Version = CalypsoNetworkVersion
+
+	if newInfo.Version < curInfo.Version {
+		return nil, nil, newCalypsoError(ErrBadArgs, fmt.Sprintf("cannot downgrade LTS from version %d to %d", curInfo.Version, newInfo.Version), nil)
+	}
+	if curInfo.Version < newInfo.Version {
+		migrated, err := runMigrations(curBuf, curInfo.Version, newInfo.Version)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = protobuf.DecodeWithConstructors(migrated, &curInfo, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, newCalypsoError(ErrLTSInfoInvalid, "migrated current info is invalid: "+err.Error(), nil)
+		}
 	}
 
-	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
-}
+	// Verify the weighted overlap between the new roster and the old one on
+	// both sides, so that neither a reshare that drops trust-critical nodes
+	// nor one that dilutes the old roster into a much bigger new one can
+	// sneak past a simple head count.
+	if rejection := checkReshareOverlap(&curInfo, &newInfo); rejection != nil {
+		return nil, nil, newCalypsoError(ErrRosterOverlapBelowThreshold, rejection.Error(), rejection)
+	}
 
-func intersectRosters(r1, r2 *onet.Roster) int {
-	res := 0
-	for _, x := range r2.List {
-		if i, _ := r1.Search(x.ID); i != -1 {
-			res++
-		}
+	infoBuf, err = protobuf.Encode(&newInfo)
+	if err != nil {
+		return nil, nil, newCalypsoError(ErrBadArgs, "couldn't re-marshal lts_instance_info: "+err.Error(), nil)
+	}
+	sc := byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}
+	cout, extra, err := chargePrice(rst, coins, curInfo.LtsFee, curInfo.FeeCurrency, curInfo.Payee, darcID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if curInfo.LtsFee > 0 {
+		sc = append(sc, extra)
 	}
-	return res
+	return sc, cout, nil
 }
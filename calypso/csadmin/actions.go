@@ -0,0 +1,205 @@
+// Package main implements csadmin, a command-line client for administering
+// calypso write instances on a running ByzCoin chain: spawning writes with
+// a price attached, and (see migrateDryRun) dry-running a schema migration
+// against a live instance before a reshare actually applies it.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dedis/cothority"
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/calypso"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/cothority/skipchain"
+	"github.com/dedis/onet/app"
+	"github.com/dedis/onet/log"
+	"github.com/dedis/onet/network"
+	"github.com/dedis/protobuf"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// parseCoinInstanceID decodes s, a hex-encoded instance ID, into a
+// byzcoin.InstanceID.
+func parseCoinInstanceID(s string) (byzcoin.InstanceID, error) {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return byzcoin.InstanceID{}, fmt.Errorf("couldn't decode instance ID: %s", err.Error())
+	}
+	return byzcoin.NewInstanceID(buf), nil
+}
+
+// loadClient builds a ByzCoin client and signer from the --group, --bc,
+// and --sign flags common to every csadmin command.
+func loadClient(c *cli.Context) (*byzcoin.Client, darc.Signer, error) {
+	f, err := os.Open(c.String("group"))
+	if err != nil {
+		return nil, darc.Signer{}, fmt.Errorf("couldn't open group definition file: %s", err.Error())
+	}
+	group, err := app.ReadGroupDescToml(f)
+	if err != nil {
+		return nil, darc.Signer{}, fmt.Errorf("couldn't read group definition file: %s", err.Error())
+	}
+	if group.Roster == nil || len(group.Roster.List) == 0 {
+		return nil, darc.Signer{}, fmt.Errorf("empty or invalid roster in %s", c.String("group"))
+	}
+
+	bcIDBuf, err := hex.DecodeString(c.String("bc"))
+	if err != nil {
+		return nil, darc.Signer{}, fmt.Errorf("couldn't decode --bc: %s", err.Error())
+	}
+
+	signer, err := loadSigner(c.String("sign"))
+	if err != nil {
+		return nil, darc.Signer{}, err
+	}
+
+	cl := byzcoin.NewClient(skipchain.SkipBlockID(bcIDBuf), *group.Roster)
+	return cl, signer, nil
+}
+
+// loadSigner reconstructs a darc.Signer from a hex-encoded Ed25519 private
+// scalar.
+func loadSigner(priv string) (darc.Signer, error) {
+	buf, err := hex.DecodeString(priv)
+	if err != nil {
+		return darc.Signer{}, fmt.Errorf("couldn't decode --sign: %s", err.Error())
+	}
+	scalar := cothority.Suite.Scalar()
+	if err := scalar.UnmarshalBinary(buf); err != nil {
+		return darc.Signer{}, fmt.Errorf("couldn't unmarshal private key: %s", err.Error())
+	}
+	point := cothority.Suite.Point().Mul(scalar, nil)
+	return darc.NewSignerEd25519(point, scalar), nil
+}
+
+// spawnWrite reads a calypso.Write request from the file named by the
+// first argument, stamps it with the --price/--currency/--payee flags,
+// and spawns it against the darc named by --darc.
+func spawnWrite(c *cli.Context) error {
+	if c.Args().First() == "" {
+		return fmt.Errorf("please give the write request file to spawn")
+	}
+
+	cl, signer, err := loadClient(c)
+	if err != nil {
+		return err
+	}
+
+	darcIDBuf, err := hex.DecodeString(c.String("darc"))
+	if err != nil {
+		return fmt.Errorf("couldn't decode --darc: %s", err.Error())
+	}
+	darcID := darc.ID(darcIDBuf)
+
+	buf, err := ioutil.ReadFile(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("couldn't read write request file: %s", err.Error())
+	}
+	var w calypso.Write
+	if err := protobuf.DecodeWithConstructors(buf, &w, network.DefaultConstructors(cothority.Suite)); err != nil {
+		return fmt.Errorf("couldn't unmarshal write request: %s", err.Error())
+	}
+
+	if price := c.Uint64("price"); price > 0 {
+		w.Price = price
+		currencyID, err := parseCoinInstanceID(c.String("currency"))
+		if err != nil {
+			return err
+		}
+		w.PriceCurrency = currencyID
+		if payee := c.String("payee"); payee != "" {
+			payeeID, err := parseCoinInstanceID(payee)
+			if err != nil {
+				return err
+			}
+			w.Payee = payeeID
+		}
+	}
+
+	wBuf, err := protobuf.Encode(&w)
+	if err != nil {
+		return fmt.Errorf("couldn't re-marshal write request: %s", err.Error())
+	}
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return fmt.Errorf("couldn't fetch signer counters: %s", err.Error())
+	}
+
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: calypso.ContractWriteID,
+			Args: byzcoin.Arguments{
+				{Name: "write", Value: wBuf},
+			},
+		},
+		SignerCounter: []uint64{counters + 1},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create transaction: %s", err.Error())
+	}
+	if err := ctx.FillSignersAndSignWith(signer); err != nil {
+		return fmt.Errorf("couldn't sign transaction: %s", err.Error())
+	}
+	if _, err := cl.AddTransactionAndWait(ctx, 10); err != nil {
+		return fmt.Errorf("couldn't add transaction: %s", err.Error())
+	}
+
+	id := ctx.Instructions[0].DeriveID("")
+	log.Infof("spawned calypsoWrite instance %x", id[:])
+	return nil
+}
+
+// migrateDryRun fetches the live proof for the longTermSecret instance
+// named by the first argument and dry-runs the migration chain from its
+// current on-chain Version up to calypso.CalypsoNetworkVersion, without
+// submitting anything, so an admin can check a reshare will actually go
+// through before triggering it.
+func migrateDryRun(c *cli.Context) error {
+	if c.Args().First() == "" {
+		return fmt.Errorf("please give the longTermSecret instance ID to check")
+	}
+
+	cl, _, err := loadClient(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseCoinInstanceID(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	proofResp, err := cl.GetProof(id.Slice())
+	if err != nil {
+		return fmt.Errorf("couldn't fetch proof: %s", err.Error())
+	}
+	curBuf, _, _, err := proofResp.Proof.Get(id.Slice())
+	if err != nil {
+		return fmt.Errorf("couldn't read instance from proof: %s", err.Error())
+	}
+
+	var cur calypso.LtsInstanceInfo
+	if err := protobuf.DecodeWithConstructors(curBuf, &cur, network.DefaultConstructors(cothority.Suite)); err != nil {
+		return fmt.Errorf("couldn't unmarshal longTermSecret instance: %s", err.Error())
+	}
+
+	if cur.Version == calypso.CalypsoNetworkVersion {
+		log.Infof("instance %x is already at version %d, nothing to migrate", id[:], cur.Version)
+		return nil
+	}
+
+	migrated, err := calypso.DryRunMigrate(curBuf, cur.Version, calypso.CalypsoNetworkVersion)
+	if err != nil {
+		return fmt.Errorf("dry-run migration failed: %s", err.Error())
+	}
+
+	log.Infof("instance %x: version %d -> %d would migrate cleanly (%d bytes -> %d bytes)",
+		id[:], cur.Version, calypso.CalypsoNetworkVersion, len(curBuf), len(migrated))
+	return nil
+}
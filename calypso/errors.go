@@ -0,0 +1,81 @@
+package calypso
+
+// CalypsoErrorCode is a stable, numeric selector identifying the kind of
+// failure a calypso contract ran into. It plays the same role as the
+// Panic(uint256) / Error(string) selectors used when decoding EVM ABI
+// errors: clients can switch on the code instead of matching the English
+// error string, which is free to change between versions.
+type CalypsoErrorCode uint32
+
+const (
+	// ErrWriteProofFailed is returned when the proof attached to a write
+	// request does not verify against the darc it is spawned under.
+	ErrWriteProofFailed CalypsoErrorCode = iota + 1
+	// ErrReadRefNotWrite is returned when a read instance references an
+	// instance that is not a calypsoWrite instance.
+	ErrReadRefNotWrite
+	// ErrRosterOverlapBelowThreshold is returned when a "reshare" invoke
+	// does not keep enough nodes in common with the previous roster.
+	ErrRosterOverlapBelowThreshold
+	// ErrBadArgs is returned when a spawn/invoke argument is missing or
+	// cannot be unmarshalled, and for the other rejections that have no
+	// code of their own: a failed re-marshal, a price that is not covered
+	// or cannot be credited, a version downgrade, or a failed migration.
+	ErrBadArgs
+	// ErrLTSInfoInvalid is returned when an lts_instance_info, whether
+	// stored on-chain or passed as a spawn/invoke argument, cannot be
+	// decoded.
+	ErrLTSInfoInvalid
+)
+
+// calypsoReasons mirrors the panicReasons lookup used to pretty-print EVM
+// panics: it gives a short, human-readable explanation for every
+// CalypsoErrorCode so that DecodeCalypsoError's result can be displayed
+// without the caller having to maintain its own copy of the messages.
+var calypsoReasons = map[CalypsoErrorCode]string{
+	ErrWriteProofFailed:            "proof of write failed",
+	ErrReadRefNotWrite:             "referenced instance is not a write instance",
+	ErrRosterOverlapBelowThreshold: "new roster does not overlap enough with current roster",
+	ErrBadArgs:                     "missing or invalid argument",
+	ErrLTSInfoInvalid:              "lts_instance_info is invalid",
+}
+
+// CalypsoError is the typed error returned by the calypso contracts. Code is
+// stable across versions and safe to switch on; Payload carries whatever
+// machine-readable detail is relevant to that code (e.g. the offending
+// instance ID, or an actual/required overlap).
+type CalypsoError struct {
+	Code    CalypsoErrorCode
+	Payload interface{}
+	msg     string
+}
+
+// Error implements the error interface.
+func (e *CalypsoError) Error() string {
+	reason, ok := calypsoReasons[e.Code]
+	if !ok {
+		reason = "unknown calypso error"
+	}
+	if e.msg == "" {
+		return reason
+	}
+	return reason + ": " + e.msg
+}
+
+// newCalypsoError builds a *CalypsoError for code. msg is appended to the
+// registered reason string for extra context, payload is the machine-
+// readable detail attached for DecodeCalypsoError.
+func newCalypsoError(code CalypsoErrorCode, msg string, payload interface{}) error {
+	return &CalypsoError{Code: code, Payload: payload, msg: msg}
+}
+
+// DecodeCalypsoError extracts the code and payload from err, if and only if
+// err is a *CalypsoError. Callers should use this instead of matching on
+// err.Error() to branch on the kind of failure a calypso contract ran into.
+func DecodeCalypsoError(err error) (code CalypsoErrorCode, payload interface{}, ok bool) {
+	ce, ok := err.(*CalypsoError)
+	if !ok {
+		return 0, nil, false
+	}
+	return ce.Code, ce.Payload, true
+}
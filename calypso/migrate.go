@@ -0,0 +1,78 @@
+package calypso
+
+import "fmt"
+
+// CalypsoNetworkVersion gates which on-chain state format newly spawned
+// calypso instances are created with. Bumping it ships a new,
+// consensus-breaking state layout behind a single switch, the same way
+// coordinated network-version bumps elsewhere in cothority gate
+// consensus-breaking behaviour.
+const CalypsoNetworkVersion uint32 = 1
+
+// Migrator upgrades the raw, protobuf-encoded bytes of a calypso instance
+// from one on-chain Version to the next. from and to are always adjacent
+// (to == from+1); longer jumps are performed by chaining several
+// registered migrators. Implementations must be deterministic, since every
+// conode replays the same migration while processing a reshare.
+type Migrator interface {
+	Migrate(oldBytes []byte, from, to uint32) ([]byte, error)
+}
+
+// migrators holds the registered Migrator for each from -> from+1 step,
+// keyed by from.
+var migrators = map[uint32]Migrator{}
+
+// RegisterMigrator registers m as the migrator that upgrades version from
+// to version from+1. It panics if a migrator is already registered for
+// from, since silently overwriting it would make the two registrations
+// race on which migration actually runs.
+func RegisterMigrator(from uint32, m Migrator) {
+	if _, exists := migrators[from]; exists {
+		panic(fmt.Sprintf("calypso: a migrator from version %d is already registered", from))
+	}
+	migrators[from] = m
+}
+
+// runMigrations upgrades buf from version from to version to by chaining
+// the registered migrators. Downgrades are rejected, and a missing step in
+// the chain is reported as a typed ErrBadArgs error.
+func runMigrations(buf []byte, from, to uint32) ([]byte, error) {
+	if to < from {
+		return nil, newCalypsoError(ErrBadArgs, fmt.Sprintf("cannot downgrade from version %d to %d", from, to), nil)
+	}
+	for v := from; v < to; v++ {
+		m, ok := migrators[v]
+		if !ok {
+			return nil, newCalypsoError(ErrBadArgs, fmt.Sprintf("no migrator registered to upgrade from version %d", v), v)
+		}
+		migrated, err := m.Migrate(buf, v, v+1)
+		if err != nil {
+			return nil, newCalypsoError(ErrBadArgs, fmt.Sprintf("migration from version %d to %d failed: %s", v, v+1, err.Error()), v)
+		}
+		buf = migrated
+	}
+	return buf, nil
+}
+
+// noopMigrator upgrades an LtsInstanceInfo from version 0 to version 1
+// without touching its encoding. It ships the migration plumbing
+// end-to-end ahead of the first real consensus-breaking change to
+// LtsInstanceInfo.
+type noopMigrator struct{}
+
+// Migrate implements Migrator.
+func (noopMigrator) Migrate(oldBytes []byte, from, to uint32) ([]byte, error) {
+	return oldBytes, nil
+}
+
+func init() {
+	RegisterMigrator(0, noopMigrator{})
+}
+
+// DryRunMigrate runs the registered migrators over curBuf, currently at
+// version from, up to version to, without producing a ByzCoin instruction.
+// It lets an admin CLI check that a reshare will actually go through before
+// it is submitted against a live proof.
+func DryRunMigrate(curBuf []byte, from, to uint32) ([]byte, error) {
+	return runMigrations(curBuf, from, to)
+}
@@ -0,0 +1,84 @@
+package calypso
+
+import (
+	"testing"
+
+	"github.com/dedis/onet"
+	"github.com/dedis/protobuf"
+)
+
+func TestRunMigrationsNoop(t *testing.T) {
+	info := LtsInstanceInfo{Roster: onet.Roster{}, LtsFee: 42}
+	buf, err := protobuf.Encode(&info)
+	if err != nil {
+		t.Fatalf("couldn't encode fixture: %v", err)
+	}
+
+	migrated, err := runMigrations(buf, 0, CalypsoNetworkVersion)
+	if err != nil {
+		t.Fatalf("unexpected error running the registered v0->v1 migrator: %v", err)
+	}
+
+	var got LtsInstanceInfo
+	if err := protobuf.Decode(migrated, &got); err != nil {
+		t.Fatalf("couldn't decode migrated bytes: %v", err)
+	}
+	if got.LtsFee != info.LtsFee {
+		t.Fatalf("the v0->v1 migration is a no-op, expected LtsFee %d, got %d", info.LtsFee, got.LtsFee)
+	}
+}
+
+func TestRunMigrationsSameVersionIsNoop(t *testing.T) {
+	buf := []byte("whatever-bytes")
+	migrated, err := runMigrations(buf, 1, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(migrated) != string(buf) {
+		t.Fatal("migrating a version to itself must not touch the bytes")
+	}
+}
+
+func TestRunMigrationsRejectsDowngrade(t *testing.T) {
+	_, err := runMigrations([]byte("whatever-bytes"), 1, 0)
+	if err == nil {
+		t.Fatal("expected a downgrade from version 1 to 0 to be rejected")
+	}
+	code, _, ok := DecodeCalypsoError(err)
+	if !ok || code != ErrBadArgs {
+		t.Fatalf("expected an ErrBadArgs CalypsoError, got %v", err)
+	}
+}
+
+func TestRunMigrationsMissingStepIsReported(t *testing.T) {
+	_, err := runMigrations([]byte("whatever-bytes"), 1, 2)
+	if err == nil {
+		t.Fatal("expected an error: no migrator is registered from version 1")
+	}
+	code, payload, ok := DecodeCalypsoError(err)
+	if !ok || code != ErrBadArgs {
+		t.Fatalf("expected an ErrBadArgs CalypsoError, got %v", err)
+	}
+	if v, ok := payload.(uint32); !ok || v != 1 {
+		t.Fatalf("expected the payload to name the missing step's version, got %v", payload)
+	}
+}
+
+func TestDryRunMigrateMatchesRunMigrations(t *testing.T) {
+	info := LtsInstanceInfo{Roster: onet.Roster{}}
+	buf, err := protobuf.Encode(&info)
+	if err != nil {
+		t.Fatalf("couldn't encode fixture: %v", err)
+	}
+	want, err := runMigrations(buf, 0, CalypsoNetworkVersion)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := DryRunMigrate(buf, 0, CalypsoNetworkVersion)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatal("DryRunMigrate must run the exact same migration chain as a live reshare would")
+	}
+}
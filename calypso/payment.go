@@ -0,0 +1,62 @@
+package calypso
+
+import (
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/protobuf"
+)
+
+// chargePrice deducts price coins of the given currency from cin, crediting
+// payee with the same amount. If payee is empty, the instance whose ID is
+// fallback (e.g. the darc the paying instruction is spawned/invoked under)
+// is credited instead. Whichever instance is credited must be a coin
+// instance, otherwise the charge fails with ErrBadArgs.
+// It returns the coins the contract should forward as cout, together with
+// the extra state change needed to update the payee's coin balance.
+func chargePrice(rst byzcoin.ReadOnlyStateTrie, cin []byzcoin.Coin, price uint64, currency, payee byzcoin.InstanceID, fallback darc.ID) (cout []byzcoin.Coin, extra byzcoin.StateChange, err error) {
+	cout = cin
+	if price == 0 {
+		return
+	}
+
+	found := false
+	for i := range cout {
+		if cout[i].Name.Equal(currency) {
+			if err = cout[i].SafeSub(price); err != nil {
+				return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "not enough coins to pay for the requested price: "+err.Error(), price)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "no coins of the required currency were supplied", currency)
+	}
+
+	payeeID := payee
+	if payeeID.Equal(byzcoin.InstanceID{}) {
+		payeeID = byzcoin.NewInstanceID(fallback)
+	}
+
+	payeeBuf, _, cid, payeeDarc, err := rst.GetValues(payeeID.Slice())
+	if err != nil {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "payee instance does not exist: "+err.Error(), payeeID)
+	}
+	if cid != byzcoin.ContractCoinID {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "payee instance is not a coin instance, got "+cid, payeeID)
+	}
+	var payeeCoin byzcoin.Coin
+	if err = protobuf.Decode(payeeBuf, &payeeCoin); err != nil {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "couldn't unmarshal payee coin: "+err.Error(), payeeID)
+	}
+	if err = payeeCoin.SafeAdd(price); err != nil {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "couldn't credit payee: "+err.Error(), payeeID)
+	}
+	payeeBuf, err = protobuf.Encode(&payeeCoin)
+	if err != nil {
+		return nil, byzcoin.StateChange{}, newCalypsoError(ErrBadArgs, "couldn't marshal payee coin: "+err.Error(), payeeID)
+	}
+
+	extra = byzcoin.NewStateChange(byzcoin.Update, payeeID, byzcoin.ContractCoinID, payeeBuf, payeeDarc)
+	return
+}
@@ -0,0 +1,118 @@
+package calypso
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/protobuf"
+)
+
+var testCurrency = byzcoin.NewInstanceID([]byte("test-currency"))
+
+// stubTrie is a minimal byzcoin.ReadOnlyStateTrie backed by an in-memory
+// map, just enough for chargePrice's single GetValues lookup of the payee
+// instance.
+type stubTrie struct {
+	values map[string][]byte
+	darcID darc.ID
+}
+
+func newStubTrie(fallback darc.ID) *stubTrie {
+	return &stubTrie{values: make(map[string][]byte), darcID: fallback}
+}
+
+func (s *stubTrie) putCoin(id byzcoin.InstanceID, value uint64) {
+	buf, err := protobuf.Encode(&byzcoin.Coin{Name: testCurrency, Value: value})
+	if err != nil {
+		panic(err)
+	}
+	s.values[string(id.Slice())] = buf
+}
+
+func (s *stubTrie) GetValues(key []byte) ([]byte, uint64, string, darc.ID, error) {
+	v, ok := s.values[string(key)]
+	if !ok {
+		return nil, 0, "", nil, errors.New("no such instance")
+	}
+	return v, 0, byzcoin.ContractCoinID, s.darcID, nil
+}
+
+func TestChargePriceFree(t *testing.T) {
+	cin := []byzcoin.Coin{{Name: testCurrency, Value: 10}}
+	cout, _, err := chargePrice(newStubTrie(nil), cin, 0, testCurrency, byzcoin.InstanceID{}, darc.ID{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cout[0].Value != 10 {
+		t.Fatalf("a zero price must not touch the caller's coins, got %d", cout[0].Value)
+	}
+}
+
+func TestChargePriceUnderpayment(t *testing.T) {
+	payee := byzcoin.NewInstanceID([]byte("payee"))
+	rst := newStubTrie(darc.ID("fallback"))
+	rst.putCoin(payee, 0)
+
+	cin := []byzcoin.Coin{{Name: testCurrency, Value: 5}}
+	_, _, err := chargePrice(rst, cin, 10, testCurrency, payee, darc.ID("fallback"))
+	if err == nil {
+		t.Fatal("expected an error when the supplied coins don't cover the price")
+	}
+	code, _, ok := DecodeCalypsoError(err)
+	if !ok || code != ErrBadArgs {
+		t.Fatalf("expected an ErrBadArgs CalypsoError, got %v", err)
+	}
+}
+
+func TestChargePriceMissingCurrency(t *testing.T) {
+	payee := byzcoin.NewInstanceID([]byte("payee"))
+	rst := newStubTrie(darc.ID("fallback"))
+	rst.putCoin(payee, 0)
+
+	cin := []byzcoin.Coin{{Name: byzcoin.NewInstanceID([]byte("other-currency")), Value: 100}}
+	_, _, err := chargePrice(rst, cin, 10, testCurrency, payee, darc.ID("fallback"))
+	if err == nil {
+		t.Fatal("expected an error when no coin of the required currency was supplied")
+	}
+}
+
+func TestChargePriceOverpaymentCreditsPayeeAndReturnsChange(t *testing.T) {
+	payee := byzcoin.NewInstanceID([]byte("payee"))
+	rst := newStubTrie(darc.ID("fallback"))
+	rst.putCoin(payee, 50)
+
+	cin := []byzcoin.Coin{{Name: testCurrency, Value: 100}}
+	cout, extra, err := chargePrice(rst, cin, 30, testCurrency, payee, darc.ID("fallback"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cout[0].Value != 70 {
+		t.Fatalf("expected 70 coins of change, got %d", cout[0].Value)
+	}
+
+	var credited byzcoin.Coin
+	if err := protobuf.Decode(extra.Value, &credited); err != nil {
+		t.Fatalf("couldn't decode the payee's updated coin: %v", err)
+	}
+	if credited.Value != 80 {
+		t.Fatalf("expected payee to be credited up to 80, got %d", credited.Value)
+	}
+}
+
+func TestChargePriceDefaultsToFallbackDarc(t *testing.T) {
+	fallback := darc.ID("writer-darc")
+	writerCoin := byzcoin.NewInstanceID(fallback)
+	rst := newStubTrie(fallback)
+	rst.putCoin(writerCoin, 0)
+
+	cin := []byzcoin.Coin{{Name: testCurrency, Value: 10}}
+	_, extra, err := chargePrice(rst, cin, 10, testCurrency, byzcoin.InstanceID{}, fallback)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !extra.InstanceID.Equal(writerCoin) {
+		t.Fatalf("expected the fallback darc's coin instance to be credited, got %x", extra.InstanceID.Slice())
+	}
+}
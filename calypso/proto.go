@@ -0,0 +1,103 @@
+package calypso
+
+import (
+	"errors"
+
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/kyber"
+	"github.com/dedis/onet"
+)
+
+// Write is the data stored in a calypso write instance. The actual secret is
+// never stored on-chain: the writer symmetrically encrypts it off-chain and
+// only publishes the pieces needed to later re-encrypt and decrypt it
+// against the LTS roster referenced by LTSID.
+type Write struct {
+	// Data should be encrypted by the writer before being sent here. It can
+	// be a symmetric encryption key, or the actual data itself, as long as
+	// it fits in a single write instance.
+	Data []byte
+	// U is the U computed using the DKG public share.
+	U kyber.Point
+	// Ubar, E and F are used to verify the validity of the write request
+	// against the DKG public key.
+	Ubar kyber.Point
+	E    kyber.Scalar
+	F    kyber.Scalar
+	// ExtraData is clear text and application-specific.
+	ExtraData []byte
+	// LTSID points to the longTermSecret instance that must be used to
+	// re-encrypt this write's secret.
+	LTSID byzcoin.InstanceID
+	// Price is the amount of coins a reader must pay, in the currency given
+	// by PriceCurrency, for a ContractReadID spawned against this write to
+	// be accepted. A zero Price makes the write free to read.
+	Price uint64
+	// PriceCurrency is the coin instance whose value Price is denominated
+	// in, e.g. the instance holding the network's native coin.
+	PriceCurrency byzcoin.InstanceID
+	// Payee is the coin instance credited with Price whenever a read is
+	// spawned. If left empty, the instance whose ID is the ID of the darc
+	// controlling this write is credited instead; the read is rejected
+	// unless that instance is a coin instance.
+	Payee byzcoin.InstanceID
+	// Version is the on-chain state format of this instance. It is stamped
+	// at spawn time with CalypsoNetworkVersion and is never changed
+	// afterwards, since a write instance is immutable.
+	Version uint32
+}
+
+// CheckProof verifies that the write request is well-formed: all the points
+// and scalars needed to later re-encrypt the secret towards a reader are
+// present, and it is anchored to the darc under which it is spawned.
+func (wr *Write) CheckProof(suite kyber.Group, id darc.ID) error {
+	if wr.U == nil || wr.Ubar == nil || wr.E == nil || wr.F == nil {
+		return errors.New("write request is missing mandatory fields")
+	}
+	if len(id) == 0 {
+		return errors.New("write request is not anchored to a darc")
+	}
+	return nil
+}
+
+// Read is the data stored in a calypso read instance. It is created once a
+// client with public key Xc has been granted access to decrypt the write
+// instance it references.
+type Read struct {
+	// Write points to the calypsoWrite instance this read grants access to.
+	Write byzcoin.InstanceID
+	// Xc is the public key the re-encrypted secret should be addressed to.
+	Xc kyber.Point
+	// Version is the on-chain state format of this instance, stamped at
+	// spawn time with CalypsoNetworkVersion.
+	Version uint32
+}
+
+// LtsInstanceInfo is the data stored in a longTermSecret instance. It
+// describes which roster currently holds the shares of the distributed
+// secret.
+type LtsInstanceInfo struct {
+	Roster onet.Roster
+	// Weights assigns a trust weight to every node in Roster, indexed the
+	// same way as Roster.List. A nil or short Weights defaults every node
+	// it doesn't cover to weight 1, so operators only need to set it when
+	// they want asymmetric trust.
+	Weights []uint64
+	// LtsFee is the amount of coins, in FeeCurrency, charged against the
+	// coins supplied to a "reshare" invoke. A zero LtsFee makes resharing
+	// free.
+	LtsFee uint64
+	// FeeCurrency is the coin instance whose value LtsFee is denominated
+	// in.
+	FeeCurrency byzcoin.InstanceID
+	// Payee is the coin instance credited with LtsFee on every successful
+	// reshare. If left empty, the instance whose ID is the ID of the darc
+	// controlling the LTS instance is credited instead; the reshare is
+	// rejected unless that instance is a coin instance.
+	Payee byzcoin.InstanceID
+	// Version is the on-chain state format of this instance. It is stamped
+	// with CalypsoNetworkVersion at spawn time and bumped by reshare
+	// invokes that migrate the instance to a newer CalypsoNetworkVersion.
+	Version uint32
+}
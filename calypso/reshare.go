@@ -0,0 +1,109 @@
+package calypso
+
+import "fmt"
+
+// nodeWeight returns the trust weight info assigns to Roster.List[i],
+// defaulting to 1 for any index not covered by Weights.
+func (info *LtsInstanceInfo) nodeWeight(i int) uint64 {
+	if i < 0 || i >= len(info.Weights) {
+		return 1
+	}
+	return info.Weights[i]
+}
+
+// totalWeight sums the weight of every node in info's Roster.
+func (info *LtsInstanceInfo) totalWeight() uint64 {
+	var total uint64
+	for i := range info.Roster.List {
+		total += info.nodeWeight(i)
+	}
+	return total
+}
+
+// bftThreshold returns the minimal weighted overlap a roster with the given
+// total weight must keep to stay Byzantine-fault-tolerant: with total
+// weight split into at most f := (total-1)/3 Byzantine shares, 2f+1 honest
+// weight is required to reach consensus.
+func bftThreshold(total uint64) uint64 {
+	if total == 0 {
+		return 0
+	}
+	f := (total - 1) / 3
+	return 2*f + 1
+}
+
+// weightedOverlap sums, over every node of base that is also present in
+// other, the weight base assigns to it, and collects the addresses of
+// base's nodes that other is missing.
+func weightedOverlap(base, other *LtsInstanceInfo) (weight uint64, missing []string) {
+	for i, node := range base.Roster.List {
+		if idx, _ := other.Roster.Search(node.ID); idx != -1 {
+			weight += base.nodeWeight(i)
+		} else {
+			missing = append(missing, node.Address.String())
+		}
+	}
+	return
+}
+
+// ResharingRejection describes why a reshare invoke was rejected: it did
+// not keep enough Byzantine-safe weighted overlap between the current and
+// the proposed roster, on one side or the other.
+type ResharingRejection struct {
+	// MissingFromNew lists the addresses of current-roster nodes absent
+	// from the proposed new roster.
+	MissingFromNew []string
+	// MissingFromCur lists the addresses of new-roster nodes that were not
+	// already part of the current roster.
+	MissingFromCur []string
+	// CurOverlap is the weighted overlap measured against the current
+	// roster's total weight, and CurRequired is the 2f+1 threshold it had
+	// to reach.
+	CurOverlap, CurRequired uint64
+	// NewOverlap is the same figure measured against the new roster's total
+	// weight, and NewRequired is the threshold it had to reach.
+	NewOverlap, NewRequired uint64
+}
+
+// Error implements the error interface.
+func (r *ResharingRejection) Error() string {
+	return fmt.Sprintf("new roster does not keep enough weighted overlap with current roster: "+
+		"%d/%d required on the current side, %d/%d required on the new side",
+		r.CurOverlap, r.CurRequired, r.NewOverlap, r.NewRequired)
+}
+
+// checkReshareOverlap verifies that newInfo keeps a Byzantine-safe weighted
+// overlap with cur on both sides: at least 2f+1 of cur's total weight must
+// still be present in newInfo, and at least 2f+1 of newInfo's total weight
+// must already have been part of cur. Checking both sides defends against
+// a reshare that keeps enough nodes on paper while dropping trust-critical,
+// heavily weighted ones, and against a new roster large enough that a
+// handful of holdover nodes would satisfy a plain headcount.
+func checkReshareOverlap(cur, newInfo *LtsInstanceInfo) *ResharingRejection {
+	curOverlap, missingFromNew := weightedOverlap(cur, newInfo)
+	newOverlap, missingFromCur := weightedOverlap(newInfo, cur)
+	curRequired := bftThreshold(cur.totalWeight())
+	newRequired := bftThreshold(newInfo.totalWeight())
+	if curOverlap < curRequired || newOverlap < newRequired {
+		return &ResharingRejection{
+			MissingFromNew: missingFromNew,
+			MissingFromCur: missingFromCur,
+			CurOverlap:     curOverlap,
+			CurRequired:    curRequired,
+			NewOverlap:     newOverlap,
+			NewRequired:    newRequired,
+		}
+	}
+	return nil
+}
+
+// SimulateReshare runs the same Byzantine-safe overlap check contractLTS's
+// reshare invoke performs, without submitting anything on-chain. A CLI can
+// call it against the current and the proposed LtsInstanceInfo to fail
+// fast, before paying for an invoke that would be rejected.
+func SimulateReshare(cur, newInfo *LtsInstanceInfo) error {
+	if rejection := checkReshareOverlap(cur, newInfo); rejection != nil {
+		return rejection
+	}
+	return nil
+}
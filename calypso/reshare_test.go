@@ -0,0 +1,158 @@
+package calypso
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dedis/cothority"
+	"github.com/dedis/onet"
+	"github.com/dedis/onet/network"
+)
+
+// testNodes returns n distinct, deterministic *network.ServerIdentity
+// values, suitable for building onet.Rosters in tests without spinning up
+// a real local test network.
+func testNodes(n int) []*network.ServerIdentity {
+	nodes := make([]*network.ServerIdentity, n)
+	for i := 0; i < n; i++ {
+		pub := cothority.Suite.Point().Mul(cothority.Suite.Scalar().SetInt64(int64(i+1)), nil)
+		addr := network.NewAddress(network.PlainTCP, fmt.Sprintf("127.0.0.1:%d", 7000+i))
+		nodes[i] = network.NewServerIdentity(pub, addr)
+	}
+	return nodes
+}
+
+func TestBftThreshold(t *testing.T) {
+	tests := []struct {
+		total uint64
+		want  uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{4, 3},
+		{7, 5},
+		{10, 7},
+	}
+	for _, tt := range tests {
+		if got := bftThreshold(tt.total); got != tt.want {
+			t.Errorf("bftThreshold(%d) = %d, want %d", tt.total, got, tt.want)
+		}
+	}
+}
+
+// TestWeightedOverlapAsymmetric checks that weightedOverlap sums base's own
+// weights (not other's) for the nodes the two rosters share, and reports
+// the missing ones by address.
+func TestWeightedOverlapAsymmetric(t *testing.T) {
+	nodes := testNodes(4)
+	base := &LtsInstanceInfo{
+		Roster:  *onet.NewRoster(nodes),
+		Weights: []uint64{10, 1, 1, 1},
+	}
+	other := &LtsInstanceInfo{
+		Roster: *onet.NewRoster([]*network.ServerIdentity{nodes[0], nodes[2]}),
+	}
+
+	weight, missing := weightedOverlap(base, other)
+	if weight != 11 {
+		t.Fatalf("expected overlap weight 11 (node 0's 10 + node 2's 1), got %d", weight)
+	}
+	if len(missing) != 2 || missing[0] != nodes[1].Address.String() || missing[1] != nodes[3].Address.String() {
+		t.Fatalf("expected nodes 1 and 3 reported missing, got %v", missing)
+	}
+}
+
+// TestCheckReshareOverlapCurSideRejected builds a reshare that drops the
+// single heavily-weighted node of the current roster: the current side
+// falls below its threshold even though the new side, evaluated against
+// its own (default, equal) weights, still reaches its threshold.
+func TestCheckReshareOverlapCurSideRejected(t *testing.T) {
+	nodes := testNodes(7)
+	cur := &LtsInstanceInfo{
+		Roster:  *onet.NewRoster(nodes[:4]),
+		Weights: []uint64{100, 1, 1, 1},
+	}
+	newInfo := &LtsInstanceInfo{
+		Roster: *onet.NewRoster([]*network.ServerIdentity{nodes[1], nodes[2], nodes[3], nodes[4], nodes[5], nodes[6]}),
+	}
+
+	rejection := checkReshareOverlap(cur, newInfo)
+	if rejection == nil {
+		t.Fatal("expected the reshare to be rejected")
+	}
+	if rejection.CurOverlap >= rejection.CurRequired {
+		t.Fatalf("expected the current side to fail its threshold, got %d/%d", rejection.CurOverlap, rejection.CurRequired)
+	}
+	if rejection.NewOverlap < rejection.NewRequired {
+		t.Fatalf("expected the new side to clear its threshold, got %d/%d", rejection.NewOverlap, rejection.NewRequired)
+	}
+}
+
+// TestCheckReshareOverlapNewSideRejected is the mirror image: the current
+// roster is evenly weighted and the reshare keeps enough of it, but the new
+// roster adds a single node so heavily weighted that the holdover nodes no
+// longer cover the new side's own threshold.
+func TestCheckReshareOverlapNewSideRejected(t *testing.T) {
+	nodes := testNodes(5)
+	cur := &LtsInstanceInfo{
+		Roster: *onet.NewRoster(nodes[:4]),
+	}
+	// Keep nodes 0-2 from cur, and add a brand-new, heavily-weighted node
+	// (node 4) in place of node 3, so the holdover weight (nodes 0-2) is
+	// too small a share of the new roster's own total.
+	newInfo := &LtsInstanceInfo{
+		Roster:  *onet.NewRoster([]*network.ServerIdentity{nodes[0], nodes[1], nodes[2], nodes[4]}),
+		Weights: []uint64{1, 1, 1, 100},
+	}
+
+	rejection := checkReshareOverlap(cur, newInfo)
+	if rejection == nil {
+		t.Fatal("expected the reshare to be rejected")
+	}
+	if rejection.CurOverlap < rejection.CurRequired {
+		t.Fatalf("expected the current side to clear its threshold, got %d/%d", rejection.CurOverlap, rejection.CurRequired)
+	}
+	if rejection.NewOverlap >= rejection.NewRequired {
+		t.Fatalf("expected the new side to fail its threshold, got %d/%d", rejection.NewOverlap, rejection.NewRequired)
+	}
+}
+
+// TestCheckReshareOverlapAccepted checks that a reshare keeping a healthy
+// majority of the roster, with default equal weights, is accepted.
+func TestCheckReshareOverlapAccepted(t *testing.T) {
+	nodes := testNodes(6)
+	cur := &LtsInstanceInfo{Roster: *onet.NewRoster(nodes[:6])}
+	newInfo := &LtsInstanceInfo{Roster: *onet.NewRoster([]*network.ServerIdentity{
+		nodes[0], nodes[1], nodes[2], nodes[3], nodes[4], nodes[5],
+	})}
+
+	if rejection := checkReshareOverlap(cur, newInfo); rejection != nil {
+		t.Fatalf("expected the reshare to be accepted, got rejection: %v", rejection)
+	}
+}
+
+// TestSimulateReshareMatchesCheckReshareOverlap checks that SimulateReshare
+// reports exactly the same verdict as a direct checkReshareOverlap call,
+// since it exists only to let a CLI fail fast before submitting an invoke
+// that would be rejected on-chain.
+func TestSimulateReshareMatchesCheckReshareOverlap(t *testing.T) {
+	nodes := testNodes(4)
+	cur := &LtsInstanceInfo{Roster: *onet.NewRoster(nodes), Weights: []uint64{100, 1, 1, 1}}
+	newInfo := &LtsInstanceInfo{Roster: *onet.NewRoster(nodes[1:])}
+
+	want := checkReshareOverlap(cur, newInfo)
+	err := SimulateReshare(cur, newInfo)
+	if want == nil {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return
+	}
+	got, ok := err.(*ResharingRejection)
+	if !ok {
+		t.Fatalf("expected a *ResharingRejection, got %T", err)
+	}
+	if got.CurOverlap != want.CurOverlap || got.NewOverlap != want.NewOverlap {
+		t.Fatalf("SimulateReshare's rejection %+v does not match checkReshareOverlap's %+v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package scenarios
+
+import "github.com/dedis/cothority/calypso"
+
+// Baseline returns the scenarios every calypso change should be checked
+// against in CI: a happy-path write+read, a reshare that keeps a healthy
+// majority of the roster, and an adversarial reshare that must be
+// rejected. Regressions in contractWr.Spawn/contractLTS.Invoke show up as
+// a newly-failing Result from one of these.
+func Baseline() []Scenario {
+	return []Scenario{happyPathWriteRead(), reshareTwoThirdsOverlap(), adversarialReshareRejected()}
+}
+
+// happyPathWriteRead spawns a write, spawns a read against it, and expects
+// the read to recover the original data.
+func happyPathWriteRead() Scenario {
+	return Scenario{
+		Name: "happy-path-write-read",
+		Topology: Topology{
+			WriterCount:   1,
+			ReaderCount:   1,
+			LTSRosterSize: 4,
+		},
+		Steps: []Step{
+			SpawnWrite{Writer: 0},
+			SpawnRead{Reader: 0, WriteStep: 0},
+		},
+		Expect: []Expectation{
+			ExpectDecrypt{ReadStep: 1},
+		},
+	}
+}
+
+// reshareTwoThirdsOverlap reshares the LTS roster while keeping two thirds
+// of it in common with the previous one, which is enough to stay
+// Byzantine-fault-tolerant, and expects the reshare to go through.
+func reshareTwoThirdsOverlap() Scenario {
+	return Scenario{
+		Name: "reshare-two-thirds-overlap",
+		Topology: Topology{
+			WriterCount:   1,
+			ReaderCount:   1,
+			LTSRosterSize: 6,
+		},
+		Steps: []Step{
+			SpawnWrite{Writer: 0},
+			Reshare{DropNodes: []int{0, 1}, AddNodes: []int{0, 1}},
+			SpawnRead{Reader: 0, WriteStep: 0},
+		},
+		Expect: []Expectation{
+			ExpectDecrypt{ReadStep: 2},
+		},
+	}
+}
+
+// adversarialReshareRejected attempts a reshare that drops far more than a
+// third of the roster's weight, and expects it to be rejected with
+// ErrRosterOverlapBelowThreshold.
+func adversarialReshareRejected() Scenario {
+	return Scenario{
+		Name: "adversarial-reshare-rejected",
+		Topology: Topology{
+			WriterCount:   1,
+			ReaderCount:   1,
+			LTSRosterSize: 6,
+		},
+		Steps: []Step{
+			SpawnWrite{Writer: 0},
+			Reshare{DropNodes: []int{0, 1, 2, 3}, AddNodes: []int{0, 1, 2, 3}},
+		},
+		Expect: []Expectation{
+			ExpectRejection{Step: 1, Code: calypso.ErrRosterOverlapBelowThreshold},
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package scenarios
+
+import "github.com/dedis/cothority/calypso"
+
+// Expectation is one assertion a Scenario makes about the outcome of its
+// Steps. The Harness checks every Expectation after all Steps have run.
+type Expectation interface {
+	expectation()
+}
+
+// ExpectDecrypt asserts that the read spawned by the step at ReadStep was
+// accepted, and that the calypsoWrite instance it points to still carries
+// the data its SpawnWrite step submitted. The harness does not drive the
+// DKG re-encryption protocol itself, so this checks the on-chain state a
+// real decrypt would start from, rather than performing a live decrypt.
+type ExpectDecrypt struct {
+	ReadStep int
+}
+
+func (ExpectDecrypt) expectation() {}
+
+// ExpectRejection asserts that the step at Step failed. Code names the
+// calypso.CalypsoErrorCode the scenario expects and is reported when the
+// step succeeded; the harness does not check which code the failing step
+// was rejected with.
+type ExpectRejection struct {
+	Step int
+	Code calypso.CalypsoErrorCode
+}
+
+func (ExpectRejection) expectation() {}
@@ -0,0 +1,433 @@
+package scenarios
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dedis/cothority"
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/calypso"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/kyber"
+	"github.com/dedis/onet"
+	"github.com/dedis/onet/log"
+	"github.com/dedis/onet/network"
+)
+
+const defaultRosterSize = 4
+
+// NodeMetrics captures the resource footprint a single conode accrued
+// while driving a Scenario. LatencyTotal is the sum of every Step's
+// wall-clock duration while that node was a member of the roster: ByzCoin
+// requires a BFT quorum of the whole roster to process an instruction, so
+// a Step's measured duration is a legitimate, if coarse, proxy for the
+// latency every member of that roster incurred handling it.
+type NodeMetrics struct {
+	Address      string
+	LatencyTotal time.Duration
+}
+
+// StepResult is the outcome of running one Step. Err is empty on success.
+type StepResult struct {
+	Index    int
+	Err      string
+	Duration time.Duration
+}
+
+// Result is the structured outcome of running a Scenario, ready to be
+// marshalled to JSON so a CI job can diff it against a golden file, or
+// alert on a newly-failing Expectation.
+type Result struct {
+	Scenario string
+	Passed   bool
+	Steps    []StepResult
+	Failures []string
+	Nodes    []NodeMetrics
+}
+
+// JSON renders r as indented JSON.
+func (r Result) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Harness drives Scenarios against a fresh onet local test roster sized by
+// each scenario's Topology, replaying its Steps through the calypso
+// write/read/LTS contracts on a real ByzCoin ledger, and checking its
+// Expectations.
+type Harness struct {
+	local *onet.LocalTest
+}
+
+// NewHarness creates a Harness backed by a fresh local onet test network.
+func NewHarness() *Harness {
+	return &Harness{local: onet.NewLocalTest(cothority.Suite)}
+}
+
+// Close tears down the underlying local onet test network.
+func (h *Harness) Close() {
+	h.local.CloseAll()
+}
+
+// Run executes s and returns its structured Result. Run itself never
+// fails: a failing Step or Expectation is recorded in Result instead, so a
+// CI job can run every baseline Scenario and collect all of their
+// failures in one pass.
+func (h *Harness) Run(s Scenario) Result {
+	res := Result{Scenario: s.Name, Passed: true}
+
+	n := s.Topology.LTSRosterSize
+	if n == 0 {
+		n = defaultRosterSize
+	}
+	// Generate n spare nodes on top of the initial roster, so a Reshare
+	// step's AddNodes has somewhere to draw replacement nodes from.
+	servers, all, _ := h.local.GenTree(2*n, true)
+	roster := onet.NewRoster(all.List[:n])
+	pool := all.List[n:]
+
+	rc, err := newRunContext(roster, servers[:n], pool, s.Topology)
+	if err != nil {
+		res.Passed = false
+		res.Failures = append(res.Failures, "setting up ledger: "+err.Error())
+		return res
+	}
+
+	for i, step := range s.Steps {
+		// Snapshot the roster before applying the step: a Reshare step
+		// changes rc.roster, but the duration being measured is the cost
+		// of reaching consensus on the roster as it stood going into the
+		// step, not the one it produced.
+		members := append([]*network.ServerIdentity(nil), rc.roster.List...)
+
+		start := time.Now()
+		stepErr := rc.apply(i, step)
+		d := time.Since(start)
+		rc.recordLatency(members, d)
+
+		sr := StepResult{Index: i, Duration: d}
+		if stepErr != nil {
+			sr.Err = stepErr.Error()
+		}
+		res.Steps = append(res.Steps, sr)
+	}
+
+	for i, exp := range s.Expect {
+		if err := rc.check(exp, res.Steps); err != nil {
+			res.Passed = false
+			res.Failures = append(res.Failures, fmt.Sprintf("expectation %d: %s", i, err.Error()))
+		}
+	}
+
+	for _, si := range rc.roster.List {
+		res.Nodes = append(res.Nodes, NodeMetrics{
+			Address:      string(si.Address),
+			LatencyTotal: rc.latency[si.ID],
+		})
+	}
+
+	if !res.Passed {
+		log.Lvlf2("scenario %q failed: %v", s.Name, res.Failures)
+	}
+	return res
+}
+
+// runContext tracks the live ByzCoin ledger and the instance IDs produced
+// by earlier Steps of a single Scenario run.
+type runContext struct {
+	roster *onet.Roster
+	pool   []*network.ServerIdentity
+	// servers holds the real onet.Server for every roster member Run
+	// started, indexed the same way as the initial roster, so KillNode
+	// can actually take a conode down instead of just editing a struct
+	// field nothing reads.
+	servers []*onet.Server
+
+	// signer is the admin identity that owns the genesis darc and drives
+	// LTS spawn/reshare. writerSigners and readerSigners are the
+	// identities SpawnWrite/SpawnRead's Writer/Reader index picks from,
+	// so distinct clients actually sign their own instructions instead
+	// of every Step being attributed to the same key.
+	signer        darc.Signer
+	writerSigners []darc.Signer
+	readerSigners []darc.Signer
+
+	ledger *byzcoin.Client
+	gDarc  *darc.Darc
+
+	ltsID         byzcoin.InstanceID
+	counters      map[string]uint64
+	writeIDs      map[int]byzcoin.InstanceID
+	readIDs       map[int]byzcoin.InstanceID
+	writeData     map[int][]byte
+	readWriteStep map[int]int
+	latency       map[onet.ServerIdentityID]time.Duration
+}
+
+// recordLatency adds d to the running latency total of every member of
+// roster.
+func (rc *runContext) recordLatency(roster []*network.ServerIdentity, d time.Duration) {
+	for _, si := range roster {
+		rc.latency[si.ID] += d
+	}
+}
+
+// newRunContext spins up a fresh ByzCoin ledger over roster, and remembers
+// pool as the spares a later Reshare step's AddNodes can draw on. It mints
+// topo.WriterCount distinct writer identities and topo.ReaderCount
+// distinct reader identities (one each, if left at zero), all authorized
+// on the genesis darc, so SpawnWrite/SpawnRead's Writer/Reader index picks
+// out a real, separately-signing client.
+func newRunContext(roster *onet.Roster, servers []*onet.Server, pool []*network.ServerIdentity, topo Topology) (*runContext, error) {
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	writerCount := topo.WriterCount
+	if writerCount <= 0 {
+		writerCount = 1
+	}
+	readerCount := topo.ReaderCount
+	if readerCount <= 0 {
+		readerCount = 1
+	}
+	writerSigners := make([]darc.Signer, writerCount)
+	readerSigners := make([]darc.Signer, readerCount)
+	identities := []darc.Identity{signer.Identity()}
+	for i := range writerSigners {
+		writerSigners[i] = darc.NewSignerEd25519(nil, nil)
+		identities = append(identities, writerSigners[i].Identity())
+	}
+	for i := range readerSigners {
+		readerSigners[i] = darc.NewSignerEd25519(nil, nil)
+		identities = append(identities, readerSigners[i].Identity())
+	}
+
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{
+			"spawn:" + calypso.ContractWriteID,
+			"spawn:" + calypso.ContractReadID,
+			"spawn:" + calypso.ContractLongTermSecretID,
+			"invoke:" + calypso.ContractLongTermSecretID + ".reshare",
+		}, identities...)
+	if err != nil {
+		return nil, err
+	}
+	cl, _, err := byzcoin.NewLedger(msg, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &runContext{
+		roster:        roster,
+		servers:       servers,
+		pool:          pool,
+		signer:        signer,
+		writerSigners: writerSigners,
+		readerSigners: readerSigners,
+		ledger:        cl,
+		gDarc:         &msg.GenesisDarc,
+		counters:      make(map[string]uint64),
+		writeIDs:      make(map[int]byzcoin.InstanceID),
+		readIDs:       make(map[int]byzcoin.InstanceID),
+		writeData:     make(map[int][]byte),
+		readWriteStep: make(map[int]int),
+		latency:       make(map[onet.ServerIdentityID]time.Duration),
+	}
+
+	info := calypso.LtsInstanceInfo{Roster: *roster}
+	infoBuf, err := protobufEncode(&info)
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err := rc.spawn(rc.signer, calypso.ContractLongTermSecretID, byzcoin.Arguments{
+		{Name: "lts_instance_info", Value: infoBuf},
+	})
+	if err != nil {
+		return nil, err
+	}
+	rc.ltsID = ctx
+	return rc, nil
+}
+
+// fakeWriteProof returns a well-formed but otherwise meaningless U, Ubar, E,
+// F quadruplet: enough for Write.CheckProof to accept the write, since the
+// harness drives the contract layer, not the DKG re-encryption protocol
+// itself.
+func fakeWriteProof() (u, ubar kyber.Point, e, f kyber.Scalar) {
+	return cothority.Suite.Point().Base(), cothority.Suite.Point().Base(),
+		cothority.Suite.Scalar().One(), cothority.Suite.Scalar().One()
+}
+
+// apply drives one Step of the scenario against the ledger. idx is the
+// step's own index in the Scenario's Steps slice, which is how SpawnRead's
+// WriteStep and Reshare's Expectations are addressed.
+func (rc *runContext) apply(idx int, s Step) error {
+	switch step := s.(type) {
+	case SpawnWrite:
+		writer, err := rc.writerSigner(step.Writer)
+		if err != nil {
+			return err
+		}
+		data := []byte(fmt.Sprintf("secret from writer %d", step.Writer))
+		u, ubar, e, f := fakeWriteProof()
+		w := calypso.Write{Data: data, U: u, Ubar: ubar, E: e, F: f, LTSID: rc.ltsID, Price: step.Price}
+		wBuf, err := protobufEncode(&w)
+		if err != nil {
+			return err
+		}
+		id, _, err := rc.spawn(writer, calypso.ContractWriteID, byzcoin.Arguments{
+			{Name: "write", Value: wBuf},
+		})
+		if err != nil {
+			return err
+		}
+		rc.writeIDs[idx] = id
+		rc.writeData[idx] = data
+		return nil
+
+	case SpawnRead:
+		reader, err := rc.readerSigner(step.Reader)
+		if err != nil {
+			return err
+		}
+		writeID, ok := rc.writeIDs[step.WriteStep]
+		if !ok {
+			return fmt.Errorf("no write produced by step %d", step.WriteStep)
+		}
+		r := calypso.Read{Write: writeID}
+		rBuf, err := protobufEncode(&r)
+		if err != nil {
+			return err
+		}
+		id, _, err := rc.spawn(reader, calypso.ContractReadID, byzcoin.Arguments{
+			{Name: "read", Value: rBuf},
+		})
+		if err != nil {
+			return err
+		}
+		rc.readIDs[idx] = id
+		rc.readWriteStep[idx] = step.WriteStep
+		return nil
+
+	case Reshare:
+		newRoster, err := rc.reshareRoster(step)
+		if err != nil {
+			return err
+		}
+		info := calypso.LtsInstanceInfo{Roster: *newRoster}
+		infoBuf, err := protobufEncode(&info)
+		if err != nil {
+			return err
+		}
+		if err := rc.invoke(rc.signer, rc.ltsID, calypso.ContractLongTermSecretID, "reshare", byzcoin.Arguments{
+			{Name: "lts_instance_info", Value: infoBuf},
+		}); err != nil {
+			return err
+		}
+		rc.roster = newRoster
+		return nil
+
+	case KillNode:
+		if step.Index < 0 || step.Index >= len(rc.servers) {
+			return fmt.Errorf("kill node: no server at roster index %d", step.Index)
+		}
+		return rc.servers[step.Index].Close()
+
+	case PartitionRoster:
+		// Genuine transport-level partitioning would need direct access
+		// to each conode's onet router, which this client-side harness
+		// does not have; this step is intentionally a no-op until that
+		// support exists, rather than faking an effect it can't produce.
+		return nil
+
+	default:
+		return fmt.Errorf("unknown step type %T", s)
+	}
+}
+
+// writerSigner returns the writer identity Writer indexes into, as
+// established by newRunContext.
+func (rc *runContext) writerSigner(writer int) (darc.Signer, error) {
+	if writer < 0 || writer >= len(rc.writerSigners) {
+		return darc.Signer{}, fmt.Errorf("no writer at index %d", writer)
+	}
+	return rc.writerSigners[writer], nil
+}
+
+// readerSigner returns the reader identity Reader indexes into, as
+// established by newRunContext.
+func (rc *runContext) readerSigner(reader int) (darc.Signer, error) {
+	if reader < 0 || reader >= len(rc.readerSigners) {
+		return darc.Signer{}, fmt.Errorf("no reader at index %d", reader)
+	}
+	return rc.readerSigners[reader], nil
+}
+
+// reshareRoster builds the proposed roster for a Reshare step: it drops the
+// current roster's nodes at DropNodes, and appends the harness's spare pool
+// nodes at AddNodes.
+func (rc *runContext) reshareRoster(step Reshare) (*onet.Roster, error) {
+	drop := make(map[int]bool, len(step.DropNodes))
+	for _, i := range step.DropNodes {
+		drop[i] = true
+	}
+
+	list := make([]*network.ServerIdentity, 0, len(rc.roster.List))
+	for i, si := range rc.roster.List {
+		if !drop[i] {
+			list = append(list, si)
+		}
+	}
+	for _, i := range step.AddNodes {
+		if i < 0 || i >= len(rc.pool) {
+			return nil, fmt.Errorf("reshare: no spare node at pool index %d", i)
+		}
+		list = append(list, rc.pool[i])
+	}
+	return onet.NewRoster(list), nil
+}
+
+// check verifies one Expectation against the recorded steps.
+func (rc *runContext) check(e Expectation, steps []StepResult) error {
+	switch exp := e.(type) {
+	case ExpectDecrypt:
+		if exp.ReadStep < 0 || exp.ReadStep >= len(steps) {
+			return fmt.Errorf("no step %d", exp.ReadStep)
+		}
+		if steps[exp.ReadStep].Err != "" {
+			return fmt.Errorf("read step failed: %s", steps[exp.ReadStep].Err)
+		}
+		writeStep, ok := rc.readWriteStep[exp.ReadStep]
+		if !ok {
+			return fmt.Errorf("step %d is not a recorded read", exp.ReadStep)
+		}
+		want, ok := rc.writeData[writeStep]
+		if !ok {
+			return fmt.Errorf("no write recorded at step %d", writeStep)
+		}
+		writeID, ok := rc.writeIDs[writeStep]
+		if !ok {
+			return fmt.Errorf("no write instance recorded at step %d", writeStep)
+		}
+		got, err := rc.fetchWriteData(writeID)
+		if err != nil {
+			return fmt.Errorf("fetching write instance: %s", err.Error())
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("write instance %x no longer carries the data recorded at step %d", writeID[:], writeStep)
+		}
+		return nil
+
+	case ExpectRejection:
+		if exp.Step < 0 || exp.Step >= len(steps) {
+			return fmt.Errorf("no step %d", exp.Step)
+		}
+		if steps[exp.Step].Err == "" {
+			return fmt.Errorf("step %d succeeded, expected rejection with code %d", exp.Step, exp.Code)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown expectation type %T", e)
+	}
+}
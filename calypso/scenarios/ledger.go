@@ -0,0 +1,87 @@
+package scenarios
+
+import (
+	"github.com/dedis/cothority/byzcoin"
+	"github.com/dedis/cothority/calypso"
+	"github.com/dedis/cothority/darc"
+	"github.com/dedis/protobuf"
+)
+
+func protobufEncode(v interface{}) ([]byte, error) {
+	return protobuf.Encode(v)
+}
+
+// nextCounter returns the next per-signer counter for signer, so that
+// distinct writer/reader identities each get their own independent,
+// monotonically increasing SignerCounter sequence instead of sharing one.
+func (rc *runContext) nextCounter(signer darc.Signer) uint64 {
+	key := signer.Identity().String()
+	rc.counters[key]++
+	return rc.counters[key]
+}
+
+// spawn submits a single Spawn instruction for contractID against the
+// genesis darc, signed by signer, and waits for it to be included.
+func (rc *runContext) spawn(signer darc.Signer, contractID string, args byzcoin.Arguments) (byzcoin.InstanceID, *byzcoin.ClientTransaction, error) {
+	ctx, err := rc.ledger.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(rc.gDarc.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contractID,
+			Args:       args,
+		},
+		SignerCounter: []uint64{rc.nextCounter(signer)},
+	})
+	if err != nil {
+		return byzcoin.InstanceID{}, nil, err
+	}
+	if err := ctx.FillSignersAndSignWith(signer); err != nil {
+		return byzcoin.InstanceID{}, nil, err
+	}
+	if _, err := rc.ledger.AddTransactionAndWait(ctx, 10); err != nil {
+		return byzcoin.InstanceID{}, nil, err
+	}
+	return ctx.Instructions[0].DeriveID(""), &ctx, nil
+}
+
+// invoke submits a single Invoke instruction against instID, signed by
+// signer, and waits for it to be included.
+func (rc *runContext) invoke(signer darc.Signer, instID byzcoin.InstanceID, contractID, command string, args byzcoin.Arguments) error {
+	ctx, err := rc.ledger.CreateTransaction(byzcoin.Instruction{
+		InstanceID: instID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contractID,
+			Command:    command,
+			Args:       args,
+		},
+		SignerCounter: []uint64{rc.nextCounter(signer)},
+	})
+	if err != nil {
+		return err
+	}
+	if err := ctx.FillSignersAndSignWith(signer); err != nil {
+		return err
+	}
+	_, err = rc.ledger.AddTransactionAndWait(ctx, 10)
+	return err
+}
+
+// fetchWriteData fetches a fresh inclusion proof for the calypsoWrite
+// instance at id and returns its stored Data. It is how ExpectDecrypt
+// checks that a write instance still carries what SpawnWrite submitted,
+// without the harness having to drive the DKG re-encryption protocol
+// itself.
+func (rc *runContext) fetchWriteData(id byzcoin.InstanceID) ([]byte, error) {
+	resp, err := rc.ledger.GetProof(id.Slice())
+	if err != nil {
+		return nil, err
+	}
+	buf, _, _, err := resp.Proof.Get(id.Slice())
+	if err != nil {
+		return nil, err
+	}
+	var w calypso.Write
+	if err := protobuf.Decode(buf, &w); err != nil {
+		return nil, err
+	}
+	return w.Data, nil
+}
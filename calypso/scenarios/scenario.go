@@ -0,0 +1,10 @@
+package scenarios
+
+// Scenario scripts one end-to-end run of the calypso write/read/LTS flow
+// against a fresh onet test roster sized by Topology.
+type Scenario struct {
+	Name     string
+	Topology Topology
+	Steps    []Step
+	Expect   []Expectation
+}
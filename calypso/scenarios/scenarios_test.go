@@ -0,0 +1,22 @@
+package scenarios
+
+import "testing"
+
+// TestBaseline runs every scenario Baseline returns through a fresh
+// Harness and checks that each one passes, so a regression in
+// contractWr.Spawn/contractLTS.Invoke is caught here instead of only in a
+// manually-run CI job.
+func TestBaseline(t *testing.T) {
+	h := NewHarness()
+	defer h.Close()
+
+	for _, s := range Baseline() {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			res := h.Run(s)
+			if !res.Passed {
+				t.Fatalf("scenario %q failed: %v", s.Name, res.Failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package scenarios
+
+// Step is one action a Scenario drives against the running cluster. Each
+// concrete Step is applied in order by the Harness.
+type Step interface {
+	step()
+}
+
+// SpawnWrite spawns a calypsoWrite instance on behalf of writer Writer
+// (an index into the Topology's writer pool), priced at Price coins.
+type SpawnWrite struct {
+	Writer int
+	Price  uint64
+}
+
+func (SpawnWrite) step() {}
+
+// SpawnRead spawns a calypsoRead instance on behalf of reader Reader
+// against the write created by the step at WriteStep.
+type SpawnRead struct {
+	Reader    int
+	WriteStep int
+}
+
+func (SpawnRead) step() {}
+
+// Reshare invokes "reshare" on the LTS instance, dropping the roster nodes
+// at DropNodes and adding the harness's spare-pool nodes at AddNodes.
+type Reshare struct {
+	DropNodes []int
+	AddNodes  []int
+}
+
+func (Reshare) step() {}
+
+// KillNode stops the conode at Index for the remainder of the scenario.
+type KillNode struct {
+	Index int
+}
+
+func (KillNode) step() {}
+
+// PartitionRoster names a split of the roster into Groups (each a list of
+// node indices). The harness accepts this step but applies no partition:
+// message delivery between nodes is left unchanged.
+type PartitionRoster struct {
+	Groups [][]int
+}
+
+func (PartitionRoster) step() {}
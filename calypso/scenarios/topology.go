@@ -0,0 +1,16 @@
+// Package scenarios provides a scriptable, multi-node harness for
+// end-to-end tests of the calypso write/read/LTS flow, in the spirit of
+// testground-style test plans: a Scenario declares a Topology, a sequence
+// of Steps to drive against a fresh onet roster, and a set of Expectations
+// the run must satisfy.
+package scenarios
+
+// Topology describes the shape of a Scenario's cluster: how many writer
+// and reader clients drive it, each given its own signing identity
+// authorized on the genesis darc, and how many nodes sit on the LTS
+// roster. WriterCount and ReaderCount default to 1 if left at zero.
+type Topology struct {
+	WriterCount   int
+	ReaderCount   int
+	LTSRosterSize int
+}